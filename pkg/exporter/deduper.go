@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/sinks"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// defaultDedupKeyTemplate groups events by the involved object and
+	// reason, folding the (possibly high-cardinality) message into a hash so
+	// it doesn't blow up the key space.
+	defaultDedupKeyTemplate = "{{.Namespace}}/{{.UID}}/{{.Reason}}/{{.MessageHash}}"
+	defaultDedupWindow      = 5 * time.Minute
+	defaultDedupCacheSize   = 4096
+)
+
+// dedupKeyData is the value exposed to a dedup key template.
+type dedupKeyData struct {
+	Namespace   string
+	UID         string
+	Reason      string
+	MessageHash string
+}
+
+// dedupEntry tracks how many times a key has been seen since it was last
+// forwarded, and when it was first seen in the current window.
+type dedupEntry struct {
+	elem      *list.Element
+	firstSeen time.Time
+	lastSeen  time.Time
+	count     int
+}
+
+// Deduper wraps a ReceiverRegistry and suppresses semantically-duplicate
+// events seen within a sliding window, mirroring the deduplicating
+// log-handler pattern used elsewhere: a small handler that keeps a map of
+// hashes and forwards to the real sink. It exists to absorb the flood of
+// near-identical events Kubernetes emits for aggregated series.
+type Deduper struct {
+	next    ReceiverRegistry
+	keyTmpl *template.Template
+	window  time.Duration
+	maxSize int
+	metric  *prometheus.CounterVec
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	lru     *list.List
+
+	logger atomic.Pointer[slog.Logger]
+}
+
+// NewDeduper builds a Deduper forwarding to next. keyTemplate, window and
+// cacheSize fall back to sane defaults when zero-valued.
+func NewDeduper(next ReceiverRegistry, keyTemplate string, window time.Duration, cacheSize int) (*Deduper, error) {
+	if keyTemplate == "" {
+		keyTemplate = defaultDedupKeyTemplate
+	}
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	if cacheSize <= 0 {
+		cacheSize = defaultDedupCacheSize
+	}
+
+	tmpl, err := template.New("dedupKey").Parse(keyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dedup key template: %w", err)
+	}
+
+	d := &Deduper{
+		next:    next,
+		keyTmpl: tmpl,
+		window:  window,
+		maxSize: cacheSize,
+		entries: make(map[string]*dedupEntry),
+		lru:     list.New(),
+		metric:  dedupedEventsVec(),
+	}
+	d.logger.Store(slog.Default())
+	return d, nil
+}
+
+var (
+	dedupedEventsOnce sync.Once
+	dedupedEvents     *prometheus.CounterVec
+)
+
+// dedupedEventsVec registers events_deduplicated_total once and shares it
+// between every Deduper, so building more than one does not panic on a
+// duplicate registration.
+func dedupedEventsVec() *prometheus.CounterVec {
+	dedupedEventsOnce.Do(func() {
+		dedupedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_deduplicated_total",
+			Help: "The total number of events suppressed as duplicates of a recently seen event",
+		}, []string{"reason"})
+	})
+	return dedupedEvents
+}
+
+// SetLogger installs the logger Deduper reports its own errors through,
+// mirroring Router.SetLogger. A nil logger is treated as slog.Default(). Safe
+// to call concurrently with SendEvent.
+func (d *Deduper) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	d.logger.Store(logger.With("component", "deduper"))
+}
+
+// SendEvent implements ReceiverRegistry. It drops ev if a semantically
+// identical event was already forwarded within the window, otherwise it
+// forwards it to next, annotating it with dedup.count/dedup.first_seen when
+// it is the first occurrence after an earlier window for the same key expired.
+func (d *Deduper) SendEvent(name string, ev *kube.EnhancedEvent) {
+	key, err := d.dedupKey(ev)
+	if err != nil {
+		d.logger.Load().Debug("failed to compute dedup key, forwarding event without deduplication", "error", err)
+		d.next.SendEvent(name, ev)
+		return
+	}
+
+	if d.observe(key, ev) {
+		d.next.SendEvent(name, ev)
+	} else {
+		d.metric.WithLabelValues(ev.Event.Reason).Inc()
+	}
+}
+
+func (d *Deduper) dedupKey(ev *kube.EnhancedEvent) (string, error) {
+	sum := sha1.Sum([]byte(ev.Event.Message))
+	data := dedupKeyData{
+		Namespace:   ev.InvolvedObject.Namespace,
+		UID:         string(ev.InvolvedObject.UID),
+		Reason:      ev.Event.Reason,
+		MessageHash: hex.EncodeToString(sum[:]),
+	}
+
+	var key strings.Builder
+	if err := d.keyTmpl.Execute(&key, data); err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// observe records ev against key and reports whether it should be forwarded:
+// true on its first occurrence, or on the first occurrence after the window
+// has expired, false while it is still within the window of a prior one.
+func (d *Deduper) observe(key string, ev *kube.EnhancedEvent) bool {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[key]
+	if !ok {
+		entry = &dedupEntry{firstSeen: now}
+		entry.elem = d.lru.PushFront(key)
+		d.entries[key] = entry
+		d.evictLocked()
+	} else if now.Sub(entry.lastSeen) <= d.window {
+		entry.lastSeen = now
+		entry.count++
+		d.lru.MoveToFront(entry.elem)
+		return false
+	} else {
+		ev.Event.Annotations = annotateDedup(ev.Event.Annotations, entry.count, entry.firstSeen)
+		entry.firstSeen = now
+		entry.count = 0
+		d.lru.MoveToFront(entry.elem)
+	}
+
+	entry.lastSeen = now
+	entry.count++
+	return true
+}
+
+// annotateDedup records how many occurrences the previous window saw (the
+// forwarded one plus any suppressed duplicates) and when that window began,
+// so downstream sinks can render something like "N occurrences in last M
+// minutes".
+func annotateDedup(annotations map[string]string, occurrences int, firstSeen time.Time) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string, 2)
+	}
+	annotations["dedup.count"] = strconv.Itoa(occurrences)
+	annotations["dedup.first_seen"] = firstSeen.Format(time.RFC3339)
+	return annotations
+}
+
+// evictLocked drops the least-recently-used keys once the cache grows past
+// maxSize. Must be called with d.mu held.
+func (d *Deduper) evictLocked() {
+	for len(d.entries) > d.maxSize {
+		oldest := d.lru.Back()
+		if oldest == nil {
+			return
+		}
+		d.lru.Remove(oldest)
+		delete(d.entries, oldest.Value.(string))
+	}
+}
+
+// Register implements ReceiverRegistry by delegating to next.
+func (d *Deduper) Register(name string, sink sinks.Sink) {
+	d.next.Register(name, sink)
+}
+
+// Close implements ReceiverRegistry by delegating to next.
+func (d *Deduper) Close() {
+	d.next.Close()
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/sinks"
+)
+
+// fakeRegistry records every event forwarded to it via SendEvent.
+type fakeRegistry struct {
+	sent []*kube.EnhancedEvent
+}
+
+func (f *fakeRegistry) SendEvent(_ string, ev *kube.EnhancedEvent) { f.sent = append(f.sent, ev) }
+func (f *fakeRegistry) Register(string, sinks.Sink)                {}
+func (f *fakeRegistry) Close()                                     {}
+
+func newTestEvent(reason string) *kube.EnhancedEvent {
+	ev := &kube.EnhancedEvent{}
+	ev.Event.Reason = reason
+	ev.Event.Message = "boom"
+	return ev
+}
+
+func TestDeduperObserveSuppressesWithinWindowThenForwardsAfterExpiry(t *testing.T) {
+	d, err := NewDeduper(&fakeRegistry{}, "", 10*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("NewDeduper: %v", err)
+	}
+
+	key := "k"
+	ev := newTestEvent("Failed")
+
+	if !d.observe(key, ev) {
+		t.Fatal("expected first occurrence to be forwarded")
+	}
+	if d.observe(key, newTestEvent("Failed")) {
+		t.Fatal("expected a repeat within the window to be suppressed")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	repeat := newTestEvent("Failed")
+	if !d.observe(key, repeat) {
+		t.Fatal("expected the first occurrence after the window expires to be forwarded")
+	}
+	if repeat.Event.Annotations["dedup.count"] != "2" {
+		t.Errorf("dedup.count = %q, want %q", repeat.Event.Annotations["dedup.count"], "2")
+	}
+	if _, ok := repeat.Event.Annotations["dedup.first_seen"]; !ok {
+		t.Error("expected dedup.first_seen annotation to be set")
+	}
+}
+
+func TestDeduperSendEventForwardsAndCountsDuplicates(t *testing.T) {
+	next := &fakeRegistry{}
+	d, err := NewDeduper(next, "", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("NewDeduper: %v", err)
+	}
+
+	d.SendEvent("receiver", newTestEvent("Failed"))
+	d.SendEvent("receiver", newTestEvent("Failed"))
+
+	if len(next.sent) != 1 {
+		t.Fatalf("forwarded events = %d, want 1 (second should be deduplicated)", len(next.sent))
+	}
+}
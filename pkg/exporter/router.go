@@ -1,12 +1,81 @@
 package exporter
 
-import "github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
+import (
+	"log/slog"
 
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
+)
+
+// GVKRoute additionally sends events whose InvolvedObject comes from a
+// specific GroupVersionKind to a receiver, alongside whatever Config.Route
+// does with the same event. This is how CRD events captured by
+// kube.CustomResourceEventWatcher (Argo Workflow events, Tekton PipelineRun
+// events, Karmada policy events, ...) reach a receiver without every Rule in
+// Config.Route needing a selector for every custom resource type, while
+// still going through Config.Route's own filtering/fan-out for anything it
+// already matches. An empty APIVersion or Kind matches any value for that
+// field.
+//
+// Config.Route/Rule selector matching is not itself extended to understand
+// apiVersion/kind; GVKRoute is the additive stopgap until that selector
+// support lands there.
+type GVKRoute struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Receiver   string `yaml:"receiver"`
+}
+
+func (g GVKRoute) matches(event *kube.EnhancedEvent) bool {
+	return (g.APIVersion == "" || g.APIVersion == event.InvolvedObject.APIVersion) &&
+		(g.Kind == "" || g.Kind == event.InvolvedObject.Kind)
+}
+
+// Router itself has no constructor in this package; its cfg and rcvr fields
+// are populated by the application's config-loading code. SetGVKRoutes and
+// SetLogger are the config loader's hooks for installing GVK routes
+// (presumably from a `gvkRoutes` section of Config) and a shared logger;
+// both are optional, and Router works fine with its zero values for
+// gvkRoutes/logger if neither is called.
 type Router struct {
-	cfg  *Config
-	rcvr ReceiverRegistry
+	cfg       *Config
+	rcvr      ReceiverRegistry
+	gvkRoutes []GVKRoute
+	logger    *slog.Logger
 }
 
+// SetGVKRoutes installs the GVK-keyed routes used to additionally dispatch
+// CRD events, on top of whatever Config.Route already does with them.
+func (r *Router) SetGVKRoutes(routes []GVKRoute) {
+	r.gvkRoutes = routes
+}
+
+// SetLogger installs the logger used for routing diagnostics. A nil logger
+// is treated as slog.Default().
+func (r *Router) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r.logger = logger.With("component", "router")
+}
+
+// ProcessEvent always runs event through Config.Route, the same as before
+// GVKRoute existed, then additionally sends it to any GVK route that
+// matches. A GVK match is additive, not a replacement: it never skips
+// Config.Route's own filtering/fan-out.
 func (r *Router) ProcessEvent(event *kube.EnhancedEvent) {
 	r.cfg.Route.ProcessEvent(event, r.rcvr)
+
+	for _, route := range r.gvkRoutes {
+		if !route.matches(event) {
+			continue
+		}
+		if r.logger != nil {
+			r.logger.Debug("routed event by GVK",
+				"apiVersion", event.InvolvedObject.APIVersion,
+				"kind", event.InvolvedObject.Kind,
+				"receiver", route.Receiver,
+			)
+		}
+		r.rcvr.SendEvent(route.Receiver, event)
+	}
 }
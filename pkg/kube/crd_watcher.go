@@ -0,0 +1,271 @@
+package kube
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// GVKConfig identifies a GroupVersionKind that CustomResourceEventWatcher
+// should watch, together with the plural resource name needed to build the
+// GroupVersionResource the dynamic informer factory requires.
+type GVKConfig struct {
+	Group    string `yaml:"group"`
+	Version  string `yaml:"version"`
+	Kind     string `yaml:"kind"`
+	Resource string `yaml:"resource"`
+}
+
+func (g GVKConfig) groupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: g.Group, Version: g.Version, Resource: g.Resource}
+}
+
+func (g GVKConfig) apiVersion() string {
+	if g.Group == "" {
+		return g.Version
+	}
+	return g.Group + "/" + g.Version
+}
+
+// CRDNormalizer maps an unstructured object received for a watched GVK into
+// the EnhancedEvent shape used by the rest of the pipeline. Pass a custom
+// one to NewCustomResourceEventWatcher for controllers whose event shape
+// isn't covered well by defaultCRDNormalize (Argo Workflow events, Tekton
+// PipelineRun events, Karmada policy events, ...).
+type CRDNormalizer func(gvk GVKConfig, obj *unstructured.Unstructured) *EnhancedEvent
+
+// CustomResourceEventWatcher watches one or more CRD-emitted
+// GroupVersionKinds through the dynamic client (events.k8s.io/v1 Events and
+// arbitrary custom resources that carry event-like information) and
+// normalizes what it sees into EnhancedEvents, so they flow through the same
+// routing/filtering/sink pipeline as core/v1 Events watched by EventWatcher.
+type CustomResourceEventWatcher struct {
+	wg           sync.WaitGroup
+	informers    []cache.SharedIndexInformer
+	stopper      chan struct{}
+	fn           EventHandler
+	normalize    CRDNormalizer
+	metricsStore *metrics.Store
+
+	// suppressWindow, when non-zero, collapses an update whose normalized
+	// reason/message exactly repeats the last one seen for an object within
+	// this long into a no-op -- a bounded, opt-in version of the same
+	// windowed-suppression idea exporter.Deduper applies at the router
+	// level, scoped here to a single watched object. Zero (the default)
+	// disables suppression entirely: every update is emitted, so a
+	// genuinely recurring reason/message is never silenced forever.
+	suppressWindow time.Duration
+
+	mu       sync.Mutex
+	lastSeen map[types.UID]crdSignature
+}
+
+// crdSignature is the last normalized reason/message observed for an object,
+// and when it was observed, used by changed to decide whether a repeat falls
+// inside suppressWindow.
+type crdSignature struct {
+	value  string
+	seenAt time.Time
+}
+
+// NewCustomResourceEventWatcher builds a watcher for the given GVKs. A nil
+// normalize func falls back to defaultCRDNormalize. suppressWindow is
+// optional; pass 0 to emit every update, as-is, with no suppression.
+func NewCustomResourceEventWatcher(config *rest.Config, namespace string, gvks []GVKConfig, metricsStore *metrics.Store, fn EventHandler, normalize CRDNormalizer, suppressWindow time.Duration) *CustomResourceEventWatcher {
+	if normalize == nil {
+		normalize = defaultCRDNormalize
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(config)
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, namespace, nil)
+
+	watcher := &CustomResourceEventWatcher{
+		stopper:        make(chan struct{}),
+		fn:             fn,
+		normalize:      normalize,
+		metricsStore:   metricsStore,
+		suppressWindow: suppressWindow,
+		lastSeen:       make(map[types.UID]crdSignature),
+	}
+
+	for _, gvk := range gvks {
+		informer := factory.ForResource(gvk.groupVersionResource()).Informer()
+		informer.AddEventHandler(watcher.handlerFor(gvk))
+		informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			watcher.metricsStore.WatchErrors.Inc()
+		})
+		watcher.informers = append(watcher.informers, informer)
+	}
+
+	return watcher
+}
+
+func (w *CustomResourceEventWatcher) handlerFor(gvk GVKConfig) cache.ResourceEventHandlerFuncs {
+	onObject := func(obj interface{}) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		w.onEvent(gvk, u)
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: onObject,
+		UpdateFunc: func(_, newObj interface{}) {
+			// Process updates as new events, same as EventWatcher does for
+			// aggregated series on core/v1 Events.
+			onObject(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			w.forgetSignature(u.GetUID())
+		},
+	}
+}
+
+func (w *CustomResourceEventWatcher) onEvent(gvk GVKConfig, obj *unstructured.Unstructured) {
+	ev := w.normalize(gvk, obj)
+	if ev == nil {
+		return
+	}
+
+	if !w.changed(obj.GetUID(), ev) {
+		return
+	}
+
+	w.metricsStore.EventsProcessed.Inc()
+	w.fn(ev)
+}
+
+// changed reports whether ev's normalized reason/message should be emitted.
+// With suppressWindow at its default of zero, it always returns true: every
+// update is emitted, same as before this field existed. When suppressWindow
+// is set, a repeat of the exact reason/message last seen for uid within that
+// window is treated as a no-op -- collapsing a flood of identical status
+// ticks from a high-churn CRD like Tekton PipelineRun or Argo Workflow
+// without permanently silencing a reason/message that later recurs for
+// real, once the window has passed.
+func (w *CustomResourceEventWatcher) changed(uid types.UID, ev *EnhancedEvent) bool {
+	if w.suppressWindow <= 0 {
+		return true
+	}
+
+	signature := ev.Reason + "|" + ev.Message
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if last, ok := w.lastSeen[uid]; ok && last.value == signature && now.Sub(last.seenAt) <= w.suppressWindow {
+		return false
+	}
+	w.lastSeen[uid] = crdSignature{value: signature, seenAt: now}
+	return true
+}
+
+// forgetSignature drops uid's tracked signature once its object is deleted,
+// so lastSeen doesn't grow forever over the life of a long-running exporter
+// watching churny CRDs.
+func (w *CustomResourceEventWatcher) forgetSignature(uid types.UID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.lastSeen, uid)
+}
+
+func (w *CustomResourceEventWatcher) Start() {
+	for _, informer := range w.informers {
+		informer := informer
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			informer.Run(w.stopper)
+		}()
+	}
+}
+
+func (w *CustomResourceEventWatcher) Stop() {
+	close(w.stopper)
+	w.wg.Wait()
+}
+
+// defaultCRDNormalize makes a best-effort attempt covering both
+// events.k8s.io/v1 Events (reason/note/regarding/type) and the
+// status-condition shape common to most other CRDs
+// (status.reason/status.message). It is intentionally lossy: controllers
+// with a richer event shape should supply their own CRDNormalizer.
+func defaultCRDNormalize(gvk GVKConfig, obj *unstructured.Unstructured) *EnhancedEvent {
+	reason, _, _ := unstructured.NestedString(obj.Object, "reason")
+	if reason == "" {
+		reason, _, _ = unstructured.NestedString(obj.Object, "status", "reason")
+	}
+
+	message, _, _ := unstructured.NestedString(obj.Object, "note")
+	if message == "" {
+		message, _, _ = unstructured.NestedString(obj.Object, "message")
+	}
+	if message == "" {
+		message, _, _ = unstructured.NestedString(obj.Object, "status", "message")
+	}
+
+	// severity: events.k8s.io/v1 and core/v1 both call this "type"
+	// (Normal/Warning); fold it into the same field on the normalized event.
+	severity, _, _ := unstructured.NestedString(obj.Object, "type")
+
+	involved := corev1.ObjectReference{
+		APIVersion: gvk.apiVersion(),
+		Kind:       gvk.Kind,
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		UID:        obj.GetUID(),
+	}
+	if regarding, found, _ := unstructured.NestedMap(obj.Object, "regarding"); found {
+		if v, ok := regarding["apiVersion"].(string); ok {
+			involved.APIVersion = v
+		}
+		if v, ok := regarding["kind"].(string); ok {
+			involved.Kind = v
+		}
+		if v, ok := regarding["name"].(string); ok {
+			involved.Name = v
+		}
+		if v, ok := regarding["namespace"].(string); ok {
+			involved.Namespace = v
+		}
+		if v, ok := regarding["uid"].(string); ok {
+			involved.UID = types.UID(v)
+		}
+	}
+
+	ev := &EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+				UID:       obj.GetUID(),
+			},
+			Reason:        reason,
+			Message:       message,
+			Type:          severity,
+			LastTimestamp: metav1.NewTime(time.Now()),
+		},
+	}
+	ev.InvolvedObject.ObjectReference = involved
+
+	return ev
+}
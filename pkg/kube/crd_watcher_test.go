@@ -0,0 +1,79 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDefaultCRDNormalizeMapsFields(t *testing.T) {
+	gvk := GVKConfig{Group: "tekton.dev", Version: "v1", Kind: "PipelineRun", Resource: "pipelineruns"}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"reason":  "Running",
+		"message": "Tasks Completed: 1",
+		"type":    "Normal",
+	}}
+	obj.SetName("my-run")
+	obj.SetNamespace("default")
+	obj.SetUID(types.UID("abc-123"))
+
+	ev := defaultCRDNormalize(gvk, obj)
+	if ev == nil {
+		t.Fatal("expected a non-nil event")
+	}
+	if ev.Reason != "Running" {
+		t.Errorf("Reason = %q, want %q", ev.Reason, "Running")
+	}
+	if ev.Message != "Tasks Completed: 1" {
+		t.Errorf("Message = %q, want %q", ev.Message, "Tasks Completed: 1")
+	}
+	if ev.Type != "Normal" {
+		t.Errorf("Type = %q, want %q", ev.Type, "Normal")
+	}
+	if ev.InvolvedObject.Kind != "PipelineRun" {
+		t.Errorf("InvolvedObject.Kind = %q, want %q", ev.InvolvedObject.Kind, "PipelineRun")
+	}
+	if ev.InvolvedObject.UID != types.UID("abc-123") {
+		t.Errorf("InvolvedObject.UID = %q, want %q", ev.InvolvedObject.UID, "abc-123")
+	}
+}
+
+func TestCustomResourceEventWatcherChangedDisabledByDefault(t *testing.T) {
+	w := &CustomResourceEventWatcher{lastSeen: make(map[types.UID]crdSignature)}
+
+	ev := &EnhancedEvent{Event: corev1.Event{Reason: "Failed", Message: "boom"}}
+	uid := types.UID("uid-1")
+
+	if !w.changed(uid, ev) {
+		t.Fatal("expected first call to report changed")
+	}
+	if !w.changed(uid, ev) {
+		t.Fatal("suppression must stay off (suppressWindow == 0): an identical repeat should still be reported as changed")
+	}
+}
+
+func TestCustomResourceEventWatcherChangedSuppressesWithinWindowThenRecovers(t *testing.T) {
+	w := &CustomResourceEventWatcher{
+		suppressWindow: 10 * time.Millisecond,
+		lastSeen:       make(map[types.UID]crdSignature),
+	}
+
+	ev := &EnhancedEvent{Event: corev1.Event{Reason: "Failed", Message: "boom"}}
+	uid := types.UID("uid-1")
+
+	if !w.changed(uid, ev) {
+		t.Fatal("expected first sighting to report changed")
+	}
+	if w.changed(uid, ev) {
+		t.Fatal("expected an identical repeat within the window to be suppressed")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !w.changed(uid, ev) {
+		t.Fatal("expected the same reason/message to be reported again once the window has passed")
+	}
+}
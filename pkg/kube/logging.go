@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// pkgLoggerPtr holds the logger new watchers are built with, behind an
+// atomic pointer so SetLogger can be called concurrently with constructors
+// reading it (e.g. from a reloading config loader) without a data race.
+var pkgLoggerPtr = func() *atomic.Pointer[slog.Logger] {
+	p := &atomic.Pointer[slog.Logger]{}
+	p.Store(slog.Default())
+	return p
+}()
+
+// pkgLogger returns the logger currently installed via SetLogger, or
+// slog.Default() if none has been.
+func pkgLogger() *slog.Logger {
+	return pkgLoggerPtr.Load()
+}
+
+// SetLogger installs the logger used by EventWatcher instances created
+// afterwards, to route their logs through the shared application logger.
+// CustomResourceEventWatcher does not log and does not read it. A nil logger
+// is ignored. Safe to call concurrently with constructors in this package.
+func SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		pkgLoggerPtr.Store(logger)
+	}
+}
@@ -0,0 +1,264 @@
+package kube
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/metrics"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// defaultSubscriptionBufferSize bounds how many recent events are kept per
+	// involved object UID before the oldest ones are dropped.
+	defaultSubscriptionBufferSize = 50
+	// defaultSubscriptionTTL evicts a UID's buffer once it has not received an
+	// event for this long.
+	defaultSubscriptionTTL = 10 * time.Minute
+	// subscriberChanSize is the buffer size of each subscriber's channel. A
+	// full channel means a slow consumer, and the event is dropped rather
+	// than blocking the demultiplexer.
+	subscriberChanSize = 16
+	// ttlSweepInterval controls how often expired UID buckets are evicted.
+	ttlSweepInterval = time.Minute
+)
+
+// eventRing is a fixed-capacity ring buffer of the most recent events for a
+// single involved object, dropping the oldest entry once full.
+type eventRing struct {
+	events []*EnhancedEvent
+}
+
+func newEventRing(capacity int) *eventRing {
+	return &eventRing{events: make([]*EnhancedEvent, 0, capacity)}
+}
+
+func (r *eventRing) push(ev *EnhancedEvent, capacity int) {
+	r.events = append(r.events, ev)
+	if over := len(r.events) - capacity; over > 0 {
+		r.events = r.events[over:]
+	}
+}
+
+func (r *eventRing) snapshot() []*EnhancedEvent {
+	out := make([]*EnhancedEvent, len(r.events))
+	copy(out, r.events)
+	return out
+}
+
+// subscriptionBucket holds the ring buffer and live subscribers for a single
+// involved object UID.
+type subscriptionBucket struct {
+	uid         types.UID
+	lruElem     *list.Element
+	ring        *eventRing
+	lastSeen    time.Time
+	subscribers map[chan *EnhancedEvent]struct{}
+}
+
+// SubscriptionRegistry lets consumers subscribe to, or look up, recent events
+// for a specific involved object UID without running their own informer. It
+// is fed by EventWatcher.onEvent through a single demultiplexer goroutine so
+// slow subscribers never block informer callbacks.
+type SubscriptionRegistry struct {
+	mu           sync.Mutex
+	buckets      map[types.UID]*subscriptionBucket
+	lru          *list.List
+	bufferSize   int
+	ttl          time.Duration
+	metricsStore *metrics.Store
+
+	events  chan *EnhancedEvent
+	stopper chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSubscriptionRegistry creates a registry that keeps up to bufferSize
+// events per UID and evicts UID buckets that have been idle for longer than
+// ttl. A bufferSize or ttl of zero falls back to the package defaults.
+func NewSubscriptionRegistry(bufferSize int, ttl time.Duration, metricsStore *metrics.Store) *SubscriptionRegistry {
+	if bufferSize <= 0 {
+		bufferSize = defaultSubscriptionBufferSize
+	}
+	if ttl <= 0 {
+		ttl = defaultSubscriptionTTL
+	}
+
+	reg := &SubscriptionRegistry{
+		buckets:      make(map[types.UID]*subscriptionBucket),
+		lru:          list.New(),
+		bufferSize:   bufferSize,
+		ttl:          ttl,
+		metricsStore: metricsStore,
+		events:       make(chan *EnhancedEvent, 256),
+		stopper:      make(chan struct{}),
+	}
+
+	reg.wg.Add(1)
+	go reg.demultiplex()
+
+	return reg
+}
+
+// Publish hands an enhanced event to the demultiplexer. It never blocks the
+// caller (the informer callback goroutine).
+func (s *SubscriptionRegistry) Publish(ev *EnhancedEvent) {
+	select {
+	case s.events <- ev:
+	default:
+		// The demultiplexer itself is backed up; drop rather than block the
+		// informer. This is distinct from a slow individual subscriber.
+		if s.metricsStore != nil {
+			s.metricsStore.DroppedSubscriptionEvents.Inc()
+		}
+	}
+}
+
+// Subscribe returns a channel of events for the given involved object UID
+// and a cancel function that must be called to unsubscribe and release
+// resources. The channel only receives events dispatched after the call;
+// events already retained for uid are not replayed (see GetEvents).
+func (s *SubscriptionRegistry) Subscribe(uid types.UID) (<-chan *EnhancedEvent, func()) {
+	ch := make(chan *EnhancedEvent, subscriberChanSize)
+
+	s.mu.Lock()
+	bucket := s.getOrCreateBucketLocked(uid)
+	bucket.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if b, ok := s.buckets[uid]; ok {
+			delete(b.subscribers, ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+// GetEvents returns a snapshot of the most recent events retained for uid, or
+// nil if no bucket exists for it. A bucket created by Subscribe before any
+// event arrived yields an empty, non-nil slice.
+func (s *SubscriptionRegistry) GetEvents(uid types.UID) []*EnhancedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[uid]
+	if !ok {
+		return nil
+	}
+	return bucket.ring.snapshot()
+}
+
+// getOrCreateBucketLocked returns the bucket for uid, creating an empty one
+// if this is the first time it is seen. A new bucket starts with lastSeen set
+// to now at the front of the LRU list; an existing bucket's recency is left
+// alone, since only an actual event arriving (see touchBucketLocked)
+// refreshes it.
+func (s *SubscriptionRegistry) getOrCreateBucketLocked(uid types.UID) *subscriptionBucket {
+	if bucket, ok := s.buckets[uid]; ok {
+		return bucket
+	}
+
+	bucket := &subscriptionBucket{
+		uid:         uid,
+		ring:        newEventRing(s.bufferSize),
+		lastSeen:    time.Now(),
+		subscribers: make(map[chan *EnhancedEvent]struct{}),
+	}
+	bucket.lruElem = s.lru.PushFront(uid)
+	s.buckets[uid] = bucket
+	return bucket
+}
+
+// touchBucketLocked records that uid just received an event, refreshing its
+// TTL and LRU recency.
+func (s *SubscriptionRegistry) touchBucketLocked(bucket *subscriptionBucket) {
+	bucket.lastSeen = time.Now()
+	s.lru.MoveToFront(bucket.lruElem)
+}
+
+func (s *SubscriptionRegistry) demultiplex() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(ttlSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev := <-s.events:
+			s.dispatch(ev)
+		case <-ticker.C:
+			s.evictExpired()
+		case <-s.stopper:
+			return
+		}
+	}
+}
+
+func (s *SubscriptionRegistry) dispatch(ev *EnhancedEvent) {
+	uid := ev.InvolvedObject.UID
+
+	s.mu.Lock()
+	bucket := s.getOrCreateBucketLocked(uid)
+	s.touchBucketLocked(bucket)
+	bucket.ring.push(ev, s.bufferSize)
+	subscribers := make([]chan *EnhancedEvent, 0, len(bucket.subscribers))
+	for ch := range bucket.subscribers {
+		subscribers = append(subscribers, ch)
+	}
+	s.mu.Unlock()
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- ev:
+		default:
+			if s.metricsStore != nil {
+				s.metricsStore.DroppedSubscriptionEvents.Inc()
+			}
+		}
+	}
+}
+
+// evictExpired removes buckets that have been idle for longer than the TTL.
+// A bucket with live subscribers is kept alive regardless of lastSeen: a
+// caller can Subscribe(uid) before any event for that UID has arrived, and
+// evicting the bucket out from under it would silently break the documented
+// channel+cancel() contract (the channel would never fire again, and cancel
+// would become a no-op on an already-gone subscriber). Because a protected
+// bucket can sit anywhere in the LRU list rather than only at the front, this
+// has to walk the whole list instead of stopping at the first non-expired
+// entry.
+func (s *SubscriptionRegistry) evictExpired() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for elem := s.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		uid := elem.Value.(types.UID)
+		bucket := s.buckets[uid]
+		switch {
+		case bucket == nil:
+			s.lru.Remove(elem)
+		case now.Sub(bucket.lastSeen) <= s.ttl:
+			// Not expired yet.
+		case len(bucket.subscribers) > 0:
+			// Expired but still subscribed to; wait for subscribers to
+			// cancel instead of evicting out from under them.
+		default:
+			s.lru.Remove(elem)
+			delete(s.buckets, uid)
+		}
+		elem = prev
+	}
+}
+
+// Stop shuts down the demultiplexer goroutine.
+func (s *SubscriptionRegistry) Stop() {
+	close(s.stopper)
+	s.wg.Wait()
+}
@@ -0,0 +1,55 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSubscriptionRegistrySurvivesTTLWhileSubscribed(t *testing.T) {
+	reg := NewSubscriptionRegistry(4, 10*time.Millisecond, nil)
+	defer reg.Stop()
+
+	uid := types.UID("test-uid")
+	ch, cancel := reg.Subscribe(uid)
+	defer cancel()
+
+	// Let the bucket go well past its TTL with no event ever arriving for
+	// it, then force an eviction sweep exactly like the periodic ticker
+	// would.
+	time.Sleep(20 * time.Millisecond)
+	reg.evictExpired()
+
+	ev := &EnhancedEvent{}
+	ev.InvolvedObject.UID = uid
+	reg.Publish(ev)
+
+	select {
+	case got := <-ch:
+		if got.InvolvedObject.UID != uid {
+			t.Fatalf("got event for uid %q, want %q", got.InvolvedObject.UID, uid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber channel never received the event; bucket was evicted out from under it")
+	}
+}
+
+func TestSubscriptionRegistryEvictsIdleUnsubscribedBuckets(t *testing.T) {
+	reg := NewSubscriptionRegistry(4, 10*time.Millisecond, nil)
+	defer reg.Stop()
+
+	uid := types.UID("idle-uid")
+	ev := &EnhancedEvent{}
+	ev.InvolvedObject.UID = uid
+	reg.Publish(ev)
+
+	// Wait for dispatch to create the bucket, then for it to go idle past
+	// the TTL before sweeping.
+	time.Sleep(20 * time.Millisecond)
+	reg.evictExpired()
+
+	if got := reg.GetEvents(uid); got != nil {
+		t.Fatalf("expected idle bucket with no subscribers to be evicted, got %d retained events", len(got))
+	}
+}
@@ -1,13 +1,14 @@
 package kube
 
 import (
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/tinybirdco/kubernetes-event-exporter/pkg/metrics"
-	"github.com/rs/zerolog/log"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -30,8 +31,13 @@ type EventWatcher struct {
 	metricsStore        *metrics.Store
 	dynamicClient       *dynamic.DynamicClient
 	clientset           *kubernetes.Clientset
+	subscriptions       *SubscriptionRegistry
+	logger              *slog.Logger
 }
 
+// NewEventWatcher builds a watcher for core/v1 Events. It logs through the
+// package-wide logger installed by SetLogger (slog.Default() if none was
+// installed).
 func NewEventWatcher(config *rest.Config, namespace string, MaxEventAgeSeconds int64, metricsStore *metrics.Store, fn EventHandler, omitLookup bool, cacheSize int) *EventWatcher {
 	clientset := kubernetes.NewForConfigOrDie(config)
 	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
@@ -47,6 +53,8 @@ func NewEventWatcher(config *rest.Config, namespace string, MaxEventAgeSeconds i
 		metricsStore:        metricsStore,
 		dynamicClient:       dynamic.NewForConfigOrDie(config),
 		clientset:           clientset,
+		subscriptions:       NewSubscriptionRegistry(0, 0, metricsStore),
+		logger:              pkgLogger().With("component", "watcher"),
 	}
 
 	// Register watcher as ResourceEventHandler to process adds, updates, deletes
@@ -86,11 +94,11 @@ func (e *EventWatcher) isEventDiscarded(event *corev1.Event) bool {
 		// Log discarded events if they were created after the watcher started
 		// (to suppres warnings from initial synchrnization)
 		if timestamp.After(startUpTime) {
-			log.Warn().
-				Str("event age", eventAge.String()).
-				Str("event namespace", event.Namespace).
-				Str("event name", event.Name).
-				Msg("Event discarded as being older than maxEventAgeSeconds")
+			e.logger.Warn("event discarded as being older than maxEventAgeSeconds",
+				"event.age", eventAge.String(),
+				"namespace", event.Namespace,
+				"event.name", event.Name,
+			)
 			e.metricsStore.EventsDiscarded.Inc()
 		}
 		return true
@@ -103,12 +111,12 @@ func (e *EventWatcher) onEvent(event *corev1.Event) {
 		return
 	}
 
-	log.Debug().
-		Str("msg", event.Message).
-		Str("namespace", event.Namespace).
-		Str("reason", event.Reason).
-		Str("involvedObject", event.InvolvedObject.Name).
-		Msg("Received event")
+	e.logger.Debug("received event",
+		"event.uid", string(event.UID),
+		"namespace", event.Namespace,
+		"reason", event.Reason,
+		"involvedObject", event.InvolvedObject.Name,
+	)
 
 	e.metricsStore.EventsProcessed.Inc()
 
@@ -124,9 +132,9 @@ func (e *EventWatcher) onEvent(event *corev1.Event) {
 		if err != nil {
 			if errors.IsNotFound(err) {
 				ev.InvolvedObject.Deleted = true
-				log.Error().Err(err).Msg("Object not found, likely deleted")
+				e.logger.Error("object not found, likely deleted", "error", err, "namespace", event.InvolvedObject.Namespace)
 			} else {
-				log.Error().Err(err).Msg("Failed to get object metadata")
+				e.logger.Error("failed to get object metadata", "error", err, "namespace", event.InvolvedObject.Namespace)
 			}
 			ev.InvolvedObject.ObjectReference = *event.InvolvedObject.DeepCopy()
 		} else {
@@ -138,9 +146,25 @@ func (e *EventWatcher) onEvent(event *corev1.Event) {
 		}
 	}
 
+	e.subscriptions.Publish(ev)
 	e.fn(ev)
 }
 
+// Subscribe returns a channel of events for the given involved object UID and
+// a cancel function that must be called to unsubscribe. This lets other
+// subsystems look up recent events for an object they care about without
+// running their own informer.
+func (e *EventWatcher) Subscribe(uid types.UID) (<-chan *EnhancedEvent, func()) {
+	return e.subscriptions.Subscribe(uid)
+}
+
+// GetEvents returns a snapshot of the most recently observed events for the
+// given involved object UID, or nil if none have been seen. If uid was only
+// subscribed to so far, the result is an empty, non-nil slice.
+func (e *EventWatcher) GetEvents(uid types.UID) []*EnhancedEvent {
+	return e.subscriptions.GetEvents(uid)
+}
+
 func (e *EventWatcher) OnDelete(obj interface{}) {
 	// Ignore deletes
 }
@@ -156,6 +180,7 @@ func (e *EventWatcher) Start() {
 func (e *EventWatcher) Stop() {
 	close(e.stopper)
 	e.wg.Wait()
+	e.subscriptions.Stop()
 }
 
 func (e *EventWatcher) setStartUpTime(time time.Time) {
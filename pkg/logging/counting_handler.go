@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesTotalOnce sync.Once
+	messagesTotal     *prometheus.CounterVec
+)
+
+// messagesTotalVec lazily registers the counter so importing this package
+// never registers a metric unless a logger built with New is actually used.
+func messagesTotalVec() *prometheus.CounterVec {
+	messagesTotalOnce.Do(func() {
+		messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "log_messages_total",
+			Help: "The total number of log messages emitted, by level",
+		}, []string{"level"})
+	})
+	return messagesTotal
+}
+
+// countingHandler increments log_messages_total{level} for every record it
+// handles, then delegates to the wrapped handler.
+type countingHandler struct {
+	slog.Handler
+}
+
+func newCountingHandler(next slog.Handler) slog.Handler {
+	return &countingHandler{Handler: next}
+}
+
+func (h *countingHandler) Handle(ctx context.Context, r slog.Record) error {
+	messagesTotalVec().WithLabelValues(r.Level.String()).Inc()
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *countingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &countingHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *countingHandler) WithGroup(name string) slog.Handler {
+	return &countingHandler{Handler: h.Handler.WithGroup(name)}
+}
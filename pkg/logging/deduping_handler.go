@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedupCacheSize bounds the set of record keys a dedupingHandler remembers;
+// it is reset wholesale once full rather than tracking per-key recency, log
+// record fan-in is high-volume enough that this is cheap and good enough.
+const dedupCacheSize = 2048
+
+// dedupingHandler collapses repeated identical log records (same level,
+// message and attributes) seen again within window into a single line, the
+// same pattern exporter.Deduper uses for events.
+type dedupingHandler struct {
+	slog.Handler
+	window time.Duration
+	// scope is the rendered form of every attr/group accumulated via
+	// WithAttrs/WithGroup so far (e.g. from logger.With("component", "...")).
+	// Those attrs live in the wrapped Handler's own state and never show up
+	// in a slog.Record's Attrs, so without this, two differently-scoped
+	// loggers emitting the same message with the same call-site attrs would
+	// be wrongly collapsed into one.
+	scope string
+
+	mu       *sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+func newDedupingHandler(next slog.Handler, window time.Duration) *dedupingHandler {
+	return &dedupingHandler{
+		Handler:  next,
+		window:   window,
+		mu:       &sync.Mutex{},
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+func (h *dedupingHandler) Handle(ctx context.Context, r slog.Record) error {
+	key := h.scope + recordKey(r)
+	now := time.Now()
+
+	h.mu.Lock()
+	last, seen := h.lastSeen[key]
+	if seen && now.Sub(last) <= h.window {
+		h.mu.Unlock()
+		return nil
+	}
+	if len(h.lastSeen) >= dedupCacheSize {
+		h.lastSeen = make(map[string]time.Time, dedupCacheSize)
+	}
+	h.lastSeen[key] = now
+	h.mu.Unlock()
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func recordKey(r slog.Record) string {
+	var sb strings.Builder
+	sb.WriteString(r.Level.String())
+	sb.WriteByte('|')
+	sb.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&sb, "|%s=%v", a.Key, a.Value)
+		return true
+	})
+	return sb.String()
+}
+
+func (h *dedupingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	sb.WriteString(h.scope)
+	for _, a := range attrs {
+		fmt.Fprintf(&sb, "|%s=%v", a.Key, a.Value)
+	}
+	return &dedupingHandler{
+		Handler:  h.Handler.WithAttrs(attrs),
+		window:   h.window,
+		scope:    sb.String(),
+		mu:       h.mu,
+		lastSeen: h.lastSeen,
+	}
+}
+
+func (h *dedupingHandler) WithGroup(name string) slog.Handler {
+	return &dedupingHandler{
+		Handler:  h.Handler.WithGroup(name),
+		window:   h.window,
+		scope:    h.scope + "|group=" + name,
+		mu:       h.mu,
+		lastSeen: h.lastSeen,
+	}
+}
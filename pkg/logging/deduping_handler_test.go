@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// countingFakeHandler just counts how many records it receives; it exists so
+// tests can assert on dedupingHandler's own behavior without caring what the
+// underlying format handler does with a record.
+type countingFakeHandler struct {
+	handled *int
+}
+
+func (h countingFakeHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h countingFakeHandler) Handle(context.Context, slog.Record) error {
+	*h.handled++
+	return nil
+}
+func (h countingFakeHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h countingFakeHandler) WithGroup(name string) slog.Handler       { return h }
+
+func newRecord(msg string) slog.Record {
+	return slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+}
+
+func TestDedupingHandlerCollapsesRepeatsWithinWindow(t *testing.T) {
+	handled := 0
+	h := newDedupingHandler(countingFakeHandler{handled: &handled}, time.Minute)
+
+	r := newRecord("hello")
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 1 {
+		t.Fatalf("handled = %d, want 1 (second identical record should have been collapsed)", handled)
+	}
+}
+
+func TestDedupingHandlerKeepsScopesSeparate(t *testing.T) {
+	handled := 0
+	base := newDedupingHandler(countingFakeHandler{handled: &handled}, time.Minute)
+
+	watcherLogger := base.WithAttrs([]slog.Attr{slog.String("component", "watcher")})
+	routerLogger := base.WithAttrs([]slog.Attr{slog.String("component", "router")})
+
+	r := newRecord("failed")
+	if err := watcherLogger.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := routerLogger.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 2 {
+		t.Fatalf("handled = %d, want 2 (same message from two different component scopes must not collapse)", handled)
+	}
+
+	// But a genuine repeat from the same scope still collapses.
+	if err := watcherLogger.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if handled != 2 {
+		t.Fatalf("handled = %d, want 2 (repeat from the same scope should have been collapsed)", handled)
+	}
+}
@@ -0,0 +1,58 @@
+// Package logging builds the single structured logger used throughout the
+// exporter, replacing the prior mixture of github.com/rs/zerolog/log and ad
+// hoc log/slog usage.
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config controls how New builds the logger, typically sourced from
+// --log.level and --log.format CLI flags (or their env var equivalents).
+type Config struct {
+	// Level is one of debug, info, warn, error. Defaults to info.
+	Level string
+	// Format is json or logfmt. Defaults to json.
+	Format string
+	// DedupWindow collapses an identical record (same level, message and
+	// attributes) seen again within this long into a single line. Zero
+	// disables deduplication.
+	DedupWindow time.Duration
+}
+
+// New builds a *slog.Logger per cfg. Every record handled also increments
+// the log_messages_total{level} Prometheus counter, so operators can alert
+// on error-log spikes.
+func New(cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(cfg.Level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "logfmt") {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	if cfg.DedupWindow > 0 {
+		handler = newDedupingHandler(handler, cfg.DedupWindow)
+	}
+	handler = newCountingHandler(handler)
+
+	return slog.New(handler)
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
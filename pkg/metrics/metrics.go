@@ -2,19 +2,17 @@ package metrics
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"sync/atomic"
 	"time"
 
-	"log/slog"
-
 	"github.com/tinybirdco/kubernetes-event-exporter/pkg/version"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/exporter-toolkit/web"
-	"github.com/rs/zerolog/log"
 )
 
 type Store struct {
@@ -25,9 +23,32 @@ type Store struct {
 	BuildInfo            prometheus.GaugeFunc
 	KubeApiReadCacheHits prometheus.Counter
 	KubeApiReadRequests  prometheus.Counter
+
+	DroppedSubscriptionEvents prometheus.Counter
 }
 
+// pkgLoggerPtr holds the logger Init reports its own errors through, behind
+// an atomic pointer so SetLogger can be called concurrently with Init
+// without a data race.
+var pkgLoggerPtr = func() *atomic.Pointer[slog.Logger] {
+	p := &atomic.Pointer[slog.Logger]{}
+	p.Store(slog.Default())
+	return p
+}()
+
+// SetLogger installs the logger used by Init, to route its logs through the
+// shared application logger. A nil logger is ignored. Safe to call
+// concurrently with Init.
+func SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		pkgLoggerPtr.Store(logger)
+	}
+}
+
+// Init starts the metrics HTTP server. It logs through the package-wide
+// logger installed by SetLogger (slog.Default() if none was installed).
 func Init(addr string, tlsConf string) {
+	logger := pkgLoggerPtr.Load()
 	// Setup the prometheus metrics machinery
 	// Add Go module build info.
 	prometheus.MustRegister(collectors.NewBuildInfoCollector())
@@ -75,12 +96,9 @@ func Init(addr string, tlsConf string) {
 	}
 
 	// start up the http listener to expose the metrics
-	// Start metrics HTTP server using standard library slog logger
-	handler := slog.NewJSONHandler(os.Stderr, nil)
-	logger := slog.New(handler)
 	go func() {
 		if err := web.ListenAndServe(&metricsServer, &metricsFlags, logger); err != nil {
-			log.Error().Err(err).Msg("metrics server failed")
+			logger.Error("metrics server failed", "error", err)
 		}
 	}()
 }
@@ -125,6 +143,10 @@ func NewMetricsStore(name_prefix string) *Store {
 			Name: name_prefix + "kube_api_read_cache_misses",
 			Help: "The total number of read requests served from kube-apiserver when looking up object metadata",
 		}),
+		DroppedSubscriptionEvents: promauto.NewCounter(prometheus.CounterOpts{
+			Name: name_prefix + "dropped_subscription_events",
+			Help: "The total number of events dropped because a per-object event subscriber's channel, or the subscription demultiplexer's queue, was full",
+		}),
 	}
 }
 
@@ -136,5 +158,6 @@ func DestroyMetricsStore(store *Store) {
 	prometheus.Unregister(store.BuildInfo)
 	prometheus.Unregister(store.KubeApiReadCacheHits)
 	prometheus.Unregister(store.KubeApiReadRequests)
+	prometheus.Unregister(store.DroppedSubscriptionEvents)
 	store = nil
 }
@@ -0,0 +1,31 @@
+package sinks
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// pkgLoggerPtr holds the logger new sinks are built with, behind an atomic
+// pointer so SetLogger can be called concurrently with constructors reading
+// it without a data race.
+var pkgLoggerPtr = func() *atomic.Pointer[slog.Logger] {
+	p := &atomic.Pointer[slog.Logger]{}
+	p.Store(slog.Default())
+	return p
+}()
+
+// pkgLogger returns the logger currently installed via SetLogger, or
+// slog.Default() if none has been.
+func pkgLogger() *slog.Logger {
+	return pkgLoggerPtr.Load()
+}
+
+// SetLogger installs the logger used by sinks created afterwards (NewLoki,
+// NewTeamsSink, ...), to route their logs through the shared application
+// logger. A nil logger is ignored. Safe to call concurrently with
+// constructors in this package.
+func SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		pkgLoggerPtr.Store(logger)
+	}
+}
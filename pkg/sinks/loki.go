@@ -2,18 +2,34 @@ package sinks
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
-	"github.com/rs/zerolog/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/push"
+)
+
+const (
+	defaultLokiBatchSize  = 100
+	defaultLokiBatchWait  = 5 * time.Second
+	defaultLokiMaxRetries = 5
+	lokiEntryQueueSize    = 1024
+	lokiBaseBackoff       = 500 * time.Millisecond
+	lokiMaxBackoff        = 30 * time.Second
 )
 
 type promtailStream struct {
@@ -33,22 +49,160 @@ type LokiConfig struct {
 	Headers      map[string]string      `yaml:"headers"`
 	Username     string                 `yaml:"username"`
 	Password     string                 `yaml:"password"`
+	TenantID     string                 `yaml:"tenantID"`
+	// BatchSize and BatchWait bound the in-memory buffer: it is flushed as
+	// soon as either limit is hit. Both default when left at zero.
+	BatchSize int           `yaml:"batchSize"`
+	BatchWait time.Duration `yaml:"batchWait"`
+	// Compression sets the Content-Encoding used for the (non-protobuf)
+	// request body: "", "gzip" or "snappy".
+	Compression string `yaml:"compression"`
+	// Protobuf serializes batches using Loki's protobuf push format instead
+	// of JSON, for higher-volume clusters.
+	Protobuf   bool `yaml:"protobuf"`
+	MaxRetries int  `yaml:"maxRetries"`
+}
+
+func (c *LokiConfig) batchSize() int {
+	if c.BatchSize <= 0 {
+		return defaultLokiBatchSize
+	}
+	return c.BatchSize
+}
+
+func (c *LokiConfig) batchWait() time.Duration {
+	if c.BatchWait <= 0 {
+		return defaultLokiBatchWait
+	}
+	return c.BatchWait
+}
+
+func (c *LokiConfig) maxRetries() int {
+	if c.MaxRetries <= 0 {
+		return defaultLokiMaxRetries
+	}
+	return c.MaxRetries
+}
+
+// lokiEntry is a single event queued for the next batch flush.
+type lokiEntry struct {
+	labelKey  string
+	labels    map[string]string
+	headers   map[string]string
+	timestamp string
+	line      string
+}
+
+// lokiBatch accumulates entries grouped by their computed label set, so
+// events sharing the same labels are emitted as a single stream with many
+// values instead of one stream per event.
+type lokiBatch struct {
+	streams map[string]*promtailStream
+	// headers is taken from the first entry added to the batch. Custom
+	// headers may be templated against the event that produced them (see
+	// Loki.processHeaders), and a batch is shared by many events, so the
+	// oldest entry in the batch stands in as the representative one.
+	headers map[string]string
+	count   int
+}
+
+func newLokiBatch() *lokiBatch {
+	return &lokiBatch{streams: make(map[string]*promtailStream)}
+}
+
+func (b *lokiBatch) add(e lokiEntry) {
+	stream, ok := b.streams[e.labelKey]
+	if !ok {
+		stream = &promtailStream{Stream: e.labels}
+		b.streams[e.labelKey] = stream
+	}
+	stream.Values = append(stream.Values, []string{e.timestamp, e.line})
+	if b.headers == nil {
+		b.headers = e.headers
+	}
+	b.count++
+}
+
+func (b *lokiBatch) empty() bool {
+	return b.count == 0
+}
+
+// lokiStatusError is returned by post when Loki responds with a non-2xx
+// status. Only 429 and 5xx are retryable.
+type lokiStatusError struct {
+	statusCode int
+	retryAfter time.Duration
+	body       string
+}
+
+func (e *lokiStatusError) Error() string {
+	return fmt.Sprintf("not successfull (2xx) response: %d: %s", e.statusCode, e.body)
+}
+
+func (e *lokiStatusError) retryable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= http.StatusInternalServerError
+}
+
+// isRetryableLokiError decides whether postWithRetry should retry err, and
+// what Retry-After (if any) to honor. A *lokiStatusError means Loki at least
+// answered, so its own retryable() rule (429/5xx) applies. Any other error
+// reaching here comes from the HTTP round trip itself -- a timeout,
+// connection reset, DNS failure, and the like -- which is just as transient
+// as a 5xx and should be retried the same way, except when it's the
+// context being canceled or timing out, which retrying can't fix.
+func isRetryableLokiError(err error) (retryable bool, retryAfter time.Duration) {
+	var statusErr *lokiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable(), statusErr.retryAfter
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+	return true, 0
 }
 
 type Loki struct {
 	cfg       *LokiConfig
 	transport *http.Transport
+	client    *http.Client
+	logger    *slog.Logger
+
+	entries chan lokiEntry
+	wg      sync.WaitGroup
+
+	// mu guards closed. Send holds a read lock around its send on entries so
+	// that Close, which holds the write lock while it closes entries, can
+	// never close the channel concurrently with a send -- without this, a
+	// Send racing a shutdown-triggered Close would panic.
+	mu     sync.RWMutex
+	closed bool
 }
 
+// NewLoki builds a Loki sink. It logs through the package-wide logger
+// installed by SetLogger (slog.Default() if none was installed).
 func NewLoki(cfg *LokiConfig) (Sink, error) {
 	tlsClientConfig, err := setupTLS(&cfg.TLS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup TLS: %w", err)
 	}
-	return &Loki{cfg: cfg, transport: &http.Transport{
+
+	transport := &http.Transport{
 		Proxy:           http.ProxyFromEnvironment,
 		TLSClientConfig: tlsClientConfig,
-	}}, nil
+	}
+
+	l := &Loki{
+		cfg:       cfg,
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+		logger:    pkgLogger().With("sink", "loki"),
+		entries:   make(chan lokiEntry, lokiEntryQueueSize),
+	}
+
+	l.wg.Add(1)
+	go l.run()
+
+	return l, nil
 }
 
 func generateTimestamp() string {
@@ -60,24 +214,17 @@ func containsTemplatePattern(s string) bool {
 	return strings.Contains(s, "{{") && strings.Contains(s, "}}")
 }
 
-func (l *Loki) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
-	eventBody, err := serializeEventWithLayout(l.cfg.Layout, ev)
-	if err != nil {
-		return err
-	}
-	timestamp := generateTimestamp()
-	
-	// Process stream labels, applying templates only to values that contain template syntax
-	processedLabels := make(map[string]string)
+func (l *Loki) processLabels(ev *kube.EnhancedEvent) map[string]string {
+	processedLabels := make(map[string]string, len(l.cfg.StreamLabels))
 	for k, v := range l.cfg.StreamLabels {
 		// Check if the value contains template syntax
 		if containsTemplatePattern(v) {
 			processed, err := GetString(ev, v)
 			if err != nil {
-				log.Debug().Err(err).Msgf("parse template for stream label failed: %s", v)
+				l.logger.Debug("parse template for stream label failed", "error", err, "template", v)
 				processedLabels[k] = v
 			} else {
-				log.Debug().Msgf("stream label: {%s: %s}", k, processed)
+				l.logger.Debug("stream label", "key", k, "value", processed)
 				processedLabels[k] = processed
 			}
 		} else {
@@ -85,60 +232,335 @@ func (l *Loki) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 			processedLabels[k] = v
 		}
 	}
-	
-	a := LokiMsg{
-		Streams: []promtailStream{{
-			Stream: processedLabels,
-			Values: [][]string{{timestamp, string(eventBody)}},
-		}},
+	return processedLabels
+}
+
+// processHeaders expands template syntax (e.g. {{ .Reason }}) in configured
+// header values against ev, the same way processLabels does for stream
+// labels. The result is attached to the lokiEntry so that whichever event
+// ends up representing the batch (see lokiBatch.headers) carries headers
+// templated against a real event rather than the literal config string.
+func (l *Loki) processHeaders(ev *kube.EnhancedEvent) map[string]string {
+	if len(l.cfg.Headers) == 0 {
+		return nil
+	}
+	processed := make(map[string]string, len(l.cfg.Headers))
+	for k, v := range l.cfg.Headers {
+		if containsTemplatePattern(v) {
+			rendered, err := GetString(ev, v)
+			if err != nil {
+				l.logger.Debug("parse template for header failed", "error", err, "template", v)
+				processed[k] = v
+			} else {
+				processed[k] = rendered
+			}
+		} else {
+			processed[k] = v
+		}
+	}
+	return processed
+}
+
+// labelKey returns a canonical string for a label set so entries sharing the
+// same labels land in the same promtailStream regardless of map order.
+func labelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
 	}
-	reqBody, err := json.Marshal(a)
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(labels[k])
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+// Send enqueues ev for the next batch flush. It only touches the network
+// from the background flusher goroutine started by NewLoki, so network
+// failures never surface here. It returns an error if ev cannot be
+// serialized, if the sink has been closed, or if the in-memory queue is
+// full, meaning the flusher can't keep up with the incoming event rate.
+func (l *Loki) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	eventBody, err := serializeEventWithLayout(l.cfg.Layout, ev)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPost, l.cfg.URL, bytes.NewBuffer(reqBody))
+
+	labels := l.processLabels(ev)
+	entry := lokiEntry{
+		labelKey:  labelKey(labels),
+		labels:    labels,
+		headers:   l.processHeaders(ev),
+		timestamp: generateTimestamp(),
+		line:      string(eventBody),
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return errors.New("loki sink is closed")
+	}
+
+	select {
+	case l.entries <- entry:
+		return nil
+	default:
+		return errors.New("loki sink queue is full, dropping event")
+	}
+}
+
+func (l *Loki) run() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.cfg.batchWait())
+	defer ticker.Stop()
+
+	batch := newLokiBatch()
+
+	for {
+		select {
+		case entry, ok := <-l.entries:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch.add(entry)
+			if batch.count >= l.cfg.batchSize() {
+				l.flush(batch)
+				batch = newLokiBatch()
+			}
+		case <-ticker.C:
+			l.flush(batch)
+			batch = newLokiBatch()
+		}
+	}
+}
+
+func (l *Loki) flush(batch *lokiBatch) {
+	if batch.empty() {
+		return
+	}
+
+	body, contentType, contentEncoding, err := l.encodeBatch(batch)
 	if err != nil {
-		return err
+		l.logger.Error("failed to encode loki batch", "error", err)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if err := l.postWithRetry(context.Background(), body, contentType, contentEncoding, batch.headers); err != nil {
+		l.logger.Error("failed to send events to loki", "error", err, "entries", batch.count)
+	}
+}
 
-	// Set basic auth if username and password are provided
-	if l.cfg.Username != "" && l.cfg.Password != "" {
-		req.SetBasicAuth(l.cfg.Username, l.cfg.Password)
+func (l *Loki) encodeBatch(batch *lokiBatch) (body []byte, contentType string, contentEncoding string, err error) {
+	if l.cfg.Protobuf {
+		return l.encodeProtobuf(batch)
 	}
+	return l.encodeJSON(batch)
+}
 
-	for k, v := range l.cfg.Headers {
-		realValue, err := GetString(ev, v)
-		if err != nil {
-			log.Debug().Err(err).Msgf("parse template failed: %s", v)
-			req.Header.Add(k, v)
-		} else {
-			log.Debug().Msgf("request header: {%s: %s}", k, realValue)
-			req.Header.Add(k, realValue)
+func (l *Loki) encodeJSON(batch *lokiBatch) ([]byte, string, string, error) {
+	msg := LokiMsg{Streams: make([]promtailStream, 0, len(batch.streams))}
+	for _, stream := range batch.streams {
+		msg.Streams = append(msg.Streams, *stream)
+	}
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	compressed, encoding, err := compress(raw, l.cfg.Compression)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return compressed, "application/json", encoding, nil
+}
+
+// encodeProtobuf serializes the batch using Loki's protobuf push format.
+// Loki's protobuf push endpoint always expects a snappy-compressed body, so
+// cfg.Compression is not consulted here.
+func (l *Loki) encodeProtobuf(batch *lokiBatch) ([]byte, string, string, error) {
+	req := push.PushRequest{Streams: make([]push.Stream, 0, len(batch.streams))}
+	for _, stream := range batch.streams {
+		entries := make([]push.Entry, 0, len(stream.Values))
+		for _, v := range stream.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, "", "", err
+			}
+			entries = append(entries, push.Entry{Timestamp: time.Unix(0, ns), Line: v[1]})
 		}
+		req.Streams = append(req.Streams, push.Stream{
+			Labels:  formatLabels(stream.Stream),
+			Entries: entries,
+		})
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	raw, err := proto.Marshal(&req)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return snappy.Encode(nil, raw), "application/x-protobuf", "snappy", nil
+}
+
+// formatLabels renders a label set using Prometheus label-matcher syntax
+// (e.g. `{namespace="default", reason="Failed"}`), as required by Loki's
+// protobuf push format.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(labels[k])
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func compress(raw []byte, compression string) ([]byte, string, error) {
+	switch compression {
+	case "gzip":
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(raw); err != nil {
+			return nil, "", err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "gzip", nil
+	case "snappy":
+		return snappy.Encode(nil, raw), "snappy", nil
+	default:
+		return raw, "", nil
+	}
+}
+
+// postWithRetry posts body to Loki, retrying 429s and 5xxs with exponential
+// backoff and jitter. A 429's Retry-After header takes precedence over the
+// computed backoff.
+func (l *Loki) postWithRetry(ctx context.Context, body []byte, contentType, contentEncoding string, headers map[string]string) error {
+	maxRetries := l.cfg.maxRetries()
+	backoff := lokiBaseBackoff
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		err := l.post(ctx, body, contentType, contentEncoding, headers)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		retryable, retryAfter := isRetryableLokiError(err)
+		if !retryable || attempt == maxRetries {
+			break
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > lokiMaxBackoff {
+			backoff = lokiMaxBackoff
+		}
+	}
+
+	return lastErr
+}
+
+func (l *Loki) post(ctx context.Context, body []byte, contentType, contentEncoding string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.cfg.URL, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	if l.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.cfg.TenantID)
+	}
+	if l.cfg.Username != "" && l.cfg.Password != "" {
+		req.SetBasicAuth(l.cfg.Username, l.cfg.Password)
+	}
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 
-	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
-		return errors.New("not successfull (2xx) response: " + string(body))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
 	}
 
-	return nil
+	return &lokiStatusError{
+		statusCode: resp.StatusCode,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		body:       string(respBody),
+	}
 }
 
+// parseRetryAfter supports both forms allowed by RFC 7231: a number of
+// seconds, or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Close stops the background flusher after draining any queued events. It is
+// safe to call concurrently with in-flight Send calls.
 func (l *Loki) Close() {
+	l.mu.Lock()
+	l.closed = true
+	close(l.entries)
+	l.mu.Unlock()
+
+	l.wg.Wait()
 	l.transport.CloseIdleConnections()
 }
@@ -0,0 +1,48 @@
+package sinks
+
+import "testing"
+
+func TestLokiBatchGroupsEntriesSharingLabels(t *testing.T) {
+	batch := newLokiBatch()
+
+	labels := map[string]string{"namespace": "default", "reason": "Failed"}
+	key := labelKey(labels)
+
+	batch.add(lokiEntry{labelKey: key, labels: labels, timestamp: "1", line: "first"})
+	batch.add(lokiEntry{labelKey: key, labels: labels, timestamp: "2", line: "second"})
+
+	if batch.count != 2 {
+		t.Fatalf("count = %d, want 2", batch.count)
+	}
+	if len(batch.streams) != 1 {
+		t.Fatalf("streams = %d, want 1 (entries sharing labels should share a stream)", len(batch.streams))
+	}
+
+	stream := batch.streams[key]
+	if len(stream.Values) != 2 {
+		t.Fatalf("stream values = %d, want 2", len(stream.Values))
+	}
+}
+
+func TestLokiBatchHeadersComeFromFirstEntry(t *testing.T) {
+	batch := newLokiBatch()
+
+	batch.add(lokiEntry{labelKey: "a", headers: map[string]string{"X-Reason": "first"}, timestamp: "1", line: "a"})
+	batch.add(lokiEntry{labelKey: "b", headers: map[string]string{"X-Reason": "second"}, timestamp: "2", line: "b"})
+
+	if got := batch.headers["X-Reason"]; got != "first" {
+		t.Fatalf("batch.headers[X-Reason] = %q, want %q (should come from the first entry added)", got, "first")
+	}
+}
+
+func TestLokiBatchEmpty(t *testing.T) {
+	batch := newLokiBatch()
+	if !batch.empty() {
+		t.Fatal("a freshly created batch should be empty")
+	}
+
+	batch.add(lokiEntry{labelKey: "a", timestamp: "1", line: "a"})
+	if batch.empty() {
+		t.Fatal("a batch with an entry should not be empty")
+	}
+}
@@ -0,0 +1,45 @@
+package sinks
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/tinybirdco/kubernetes-event-exporter/pkg/kube"
+)
+
+func TestLokiSendAfterCloseReturnsError(t *testing.T) {
+	sink, err := NewLoki(&LokiConfig{URL: "http://127.0.0.1:0/loki/api/v1/push"})
+	if err != nil {
+		t.Fatalf("NewLoki: %v", err)
+	}
+	loki := sink.(*Loki)
+	loki.Close()
+
+	if err := loki.Send(context.Background(), &kube.EnhancedEvent{}); err == nil {
+		t.Fatal("expected Send after Close to return an error instead of touching the closed entries channel")
+	}
+}
+
+func TestLokiCloseConcurrentWithSendDoesNotPanic(t *testing.T) {
+	sink, err := NewLoki(&LokiConfig{URL: "http://127.0.0.1:0/loki/api/v1/push"})
+	if err != nil {
+		t.Fatalf("NewLoki: %v", err)
+	}
+	loki := sink.(*Loki)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Ignore the error: the only thing under test is that this
+			// never panics from a send on a closed channel, win or lose
+			// the race with Close below.
+			_ = loki.Send(context.Background(), &kube.EnhancedEvent{})
+		}()
+	}
+
+	loki.Close()
+	wg.Wait()
+}
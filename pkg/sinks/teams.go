@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -18,12 +19,15 @@ type TeamsConfig struct {
 	Headers  map[string]string      `yaml:"headers"`
 }
 
+// NewTeamsSink builds a Teams sink. It logs through the package-wide logger
+// installed by SetLogger (slog.Default() if none was installed).
 func NewTeamsSink(cfg *TeamsConfig) (Sink, error) {
-	return &Teams{cfg: cfg}, nil
+	return &Teams{cfg: cfg, logger: pkgLogger().With("sink", "teams")}, nil
 }
 
 type Teams struct {
-	cfg *TeamsConfig
+	cfg    *TeamsConfig
+	logger *slog.Logger
 }
 
 func (w *Teams) Close() {
@@ -59,6 +63,7 @@ func (w *Teams) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		w.logger.Error("failed to send event to teams", "error", err)
 		return nil
 	}
 